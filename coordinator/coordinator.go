@@ -0,0 +1,368 @@
+// coordinator package corresponds to what the CRAQ white paper refers to as
+// the chain coordinator. It tracks chain membership, assigns each node's
+// position, and reconfigures the chain when nodes join or leave.
+package coordinator
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/despreston/go-craq/craqrpc"
+	"github.com/despreston/go-craq/transport"
+)
+
+// ErrNotFound is returned when a node can't be located by ID or address.
+var ErrNotFound = errors.New("no such node")
+
+// member is a node registered with the coordinator.
+type member struct {
+	id     string
+	addrs  []string
+	client transport.Client
+
+	// pingFailures counts consecutive failed liveness pings. A node is only
+	// considered dead, and the chain reconfigured around it, once this
+	// exceeds the coordinator's livenessFailureThreshold, so a brief network
+	// blip doesn't cause a reshuffle.
+	pingFailures int
+}
+
+func (m *member) path() string {
+	if len(m.addrs) == 0 {
+		return ""
+	}
+	return m.addrs[0]
+}
+
+// Opts is for passing options to the Coordinator constructor.
+type Opts struct {
+	Transport transport.Transporter
+
+	// LivenessFailureThreshold is how many consecutive failed liveness pings
+	// a node may accrue before it's removed from the chain. Defaults to 3.
+	LivenessFailureThreshold int
+}
+
+// Coordinator tracks chain membership, assigns each node's position, and
+// reconfigures the chain when nodes join or leave.
+type Coordinator struct {
+	mu                       sync.Mutex
+	chain                    []*member // ordered head -> tail
+	byID                     map[string]*member
+	transport                transport.Transporter
+	livenessFailureThreshold int
+}
+
+// New creates a new Coordinator.
+func New(opts Opts) *Coordinator {
+	threshold := opts.LivenessFailureThreshold
+	if threshold == 0 {
+		threshold = 3
+	}
+
+	return &Coordinator{
+		byID:                     make(map[string]*member),
+		transport:                opts.Transport,
+		livenessFailureThreshold: threshold,
+	}
+}
+
+// RPC wraps Coordinator to satisfy the net/rpc calling convention.
+type RPC struct{ c *Coordinator }
+
+// RegisterNode adds a new node to the tail of the chain, or, if ID names a
+// node that's already registered, replaces its address in place and keeps
+// its existing chain position instead of tearing it down.
+func (r *RPC) RegisterNode(args *craqrpc.RegisterNodeArgs, reply *craqrpc.NodeMeta) error {
+	meta, err := r.c.registerNode(args.ID, args.Addrs)
+	if err != nil {
+		return err
+	}
+	*reply = meta
+	return nil
+}
+
+// RemoveNode deregisters a node by ID and reconfigures the chain around it.
+// Nodes call this themselves during a graceful Stop.
+func (r *RPC) RemoveNode(id string, _ *struct{}) error {
+	return r.c.removeByID(id)
+}
+
+// ReportBadNode deregisters the node at args.Path and reconfigures the
+// chain around it. Nodes call this once a neighbor's consecutive RPC
+// failures exceed their MaxPeerErrors threshold.
+func (r *RPC) ReportBadNode(args *craqrpc.BadNodeArgs, _ *struct{}) error {
+	return r.c.removeByAddr(args.Path)
+}
+
+// registerNode implements RPC.RegisterNode.
+func (c *Coordinator) registerNode(id string, addrs []string) (craqrpc.NodeMeta, error) {
+	c.mu.Lock()
+
+	if m, ok := c.byID[id]; ok {
+		meta, pushes, err := c.reconnectMember(m, addrs)
+		c.mu.Unlock()
+		if err != nil {
+			return craqrpc.NodeMeta{}, err
+		}
+		c.pushAll(pushes)
+		return meta, nil
+	}
+
+	client, err := c.transport.ConnectGroup(addrs)
+	if err != nil {
+		c.mu.Unlock()
+		return craqrpc.NodeMeta{}, err
+	}
+
+	m := &member{id: id, addrs: addrs, client: client}
+	c.byID[id] = m
+	c.chain = append(c.chain, m)
+
+	// m is the new tail: every other member's Tail pointer is now stale.
+	meta := c.metaFor(m)
+	pushes := c.tailBroadcast(m)
+	c.mu.Unlock()
+
+	c.pushAll(pushes)
+	return meta, nil
+}
+
+// reconnectMember updates a known node's address in place, keeping its
+// chain position, and returns the SetNeighbor pushes needed to tell the
+// rest of the chain about the new address: if m is the tail, every other
+// member's Tail pointer needs it; otherwise only m's immediate successor
+// (the one member that pulls propagation from m) needs its Prev pointer
+// updated. Caller must hold c.mu; the returned pushes must be delivered
+// after releasing it.
+func (c *Coordinator) reconnectMember(m *member, addrs []string) (craqrpc.NodeMeta, []pendingPush, error) {
+	client, err := c.transport.ConnectGroup(addrs)
+	if err != nil {
+		return craqrpc.NodeMeta{}, nil, err
+	}
+
+	if m.client != nil {
+		m.client.Close()
+	}
+	m.client = client
+	m.addrs = addrs
+	m.pingFailures = 0
+
+	log.Printf("node %s reconnected at %v, keeping its chain position\n", m.id, addrs)
+
+	idx := c.indexOf(m)
+	var pushes []pendingPush
+	if idx == len(c.chain)-1 {
+		pushes = c.tailBroadcast(m)
+	} else if idx < len(c.chain)-1 {
+		pushes = []pendingPush{{m: c.chain[idx+1], pos: craqrpc.NeighborPosPrev, addrs: addrs}}
+	}
+
+	return c.metaFor(m), pushes, nil
+}
+
+// metaFor describes m's current position in the chain, for handing back as
+// an RPC.RegisterNode reply.
+func (c *Coordinator) metaFor(m *member) craqrpc.NodeMeta {
+	idx := c.indexOf(m)
+	meta := craqrpc.NodeMeta{
+		IsHead: idx == 0,
+		IsTail: idx == len(c.chain)-1,
+		Tail:   c.chain[len(c.chain)-1].addrs,
+	}
+	if idx > 0 {
+		meta.Prev = c.chain[idx-1].addrs
+	}
+	return meta
+}
+
+func (c *Coordinator) indexOf(m *member) int {
+	for i, other := range c.chain {
+		if other == m {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeByID deregisters the node with the given ID and reconfigures the
+// chain around it. Caller must not hold c.mu.
+func (c *Coordinator) removeByID(id string) error {
+	c.mu.Lock()
+	m, ok := c.byID[id]
+	if !ok {
+		c.mu.Unlock()
+		return fmt.Errorf("%w: id %q", ErrNotFound, id)
+	}
+	pushes := c.remove(m)
+	c.mu.Unlock()
+
+	c.pushAll(pushes)
+	return nil
+}
+
+// removeByAddr deregisters the node reachable at addr and reconfigures the
+// chain around it. Caller must not hold c.mu.
+func (c *Coordinator) removeByAddr(addr string) error {
+	c.mu.Lock()
+	var target *member
+	for _, m := range c.chain {
+		if m.path() == addr {
+			target = m
+			break
+		}
+	}
+	if target == nil {
+		c.mu.Unlock()
+		return fmt.Errorf("%w: addr %q", ErrNotFound, addr)
+	}
+	pushes := c.remove(target)
+	c.mu.Unlock()
+
+	c.pushAll(pushes)
+	return nil
+}
+
+// remove drops m from the chain and returns the SetNeighbor pushes needed
+// to reconfigure the survivors around the gap: if m was the tail, the new
+// tail is told it's now the tail and every other member is told the new
+// tail's address; otherwise only m's former successor needs its Prev
+// pointer updated (to m's former predecessor, or cleared if m was the
+// head). Caller must hold c.mu; the returned pushes must be delivered
+// after releasing it.
+func (c *Coordinator) remove(m *member) []pendingPush {
+	idx := c.indexOf(m)
+	if idx == -1 {
+		return nil
+	}
+
+	wasTail := idx == len(c.chain)-1
+	c.chain = append(c.chain[:idx], c.chain[idx+1:]...)
+	delete(c.byID, m.id)
+	if m.client != nil {
+		m.client.Close()
+	}
+
+	log.Printf("removed node %s from the chain, reconfiguring survivors\n", m.id)
+
+	if wasTail {
+		if len(c.chain) == 0 {
+			return nil
+		}
+		newTail := c.chain[len(c.chain)-1]
+		pushes := []pendingPush{{m: newTail, pos: craqrpc.NeighborPosTail, addrs: nil}}
+		return append(pushes, c.tailBroadcast(newTail)...)
+	}
+
+	// idx now holds what was m's successor; its Prev pointer must follow to
+	// m's old predecessor (or be cleared, electing it as the new head).
+	var prevAddrs []string
+	if idx > 0 {
+		prevAddrs = c.chain[idx-1].addrs
+	}
+	return []pendingPush{{m: c.chain[idx], pos: craqrpc.NeighborPosPrev, addrs: prevAddrs}}
+}
+
+// pendingPush describes a node-side RPC.SetNeighbor call to make once c.mu
+// has been released.
+type pendingPush struct {
+	m     *member
+	pos   craqrpc.NeighborPos
+	addrs []string
+}
+
+// tailBroadcast returns the pushes needed to tell every member other than
+// tail that tail is now the chain's tail, e.g. after a join or after the
+// old tail is removed. Caller must hold c.mu.
+func (c *Coordinator) tailBroadcast(tail *member) []pendingPush {
+	pushes := make([]pendingPush, 0, len(c.chain))
+	for _, other := range c.chain {
+		if other == tail {
+			continue
+		}
+		pushes = append(pushes, pendingPush{m: other, pos: craqrpc.NeighborPosTail, addrs: tail.addrs})
+	}
+	return pushes
+}
+
+// pushAll delivers pushes via pushNeighbor. Must be called without holding
+// c.mu: each push is a blocking RPC, and holding the coordinator's single
+// mutex across it would wedge every other RegisterNode/RemoveNode/
+// ReportBadNode/ChainStatus call, including the PingAll sweep that would
+// otherwise detect and heal an unresponsive member.
+func (c *Coordinator) pushAll(pushes []pendingPush) {
+	for _, p := range pushes {
+		c.pushNeighbor(p.m, p.pos, p.addrs)
+	}
+}
+
+// pushNeighbor tells m to set its neighbor at pos to addrs via the
+// node-side RPC.SetNeighbor, so survivors don't have to wait for their own
+// reconnect cycle to pick up the new chain topology. addrs may be empty,
+// meaning m no longer has a neighbor at pos.
+func (c *Coordinator) pushNeighbor(m *member, pos craqrpc.NeighborPos, addrs []string) {
+	args := craqrpc.SetNeighborArgs{Pos: pos, Addrs: addrs}
+	if err := m.client.Call("RPC.SetNeighbor", &args, &struct{}{}); err != nil {
+		log.Printf("failed to push neighbor update to %s: %v\n", m.id, err)
+	}
+}
+
+// ChainStatus fans RPC.Status out to every registered node and returns
+// their statuses in chain order (head to tail).
+func (r *RPC) ChainStatus(_ struct{}, reply *craqrpc.ChainStatus) error {
+	*reply = r.c.chainStatus()
+	return nil
+}
+
+// chainStatus implements RPC.ChainStatus.
+func (c *Coordinator) chainStatus() craqrpc.ChainStatus {
+	c.mu.Lock()
+	members := make([]*member, len(c.chain))
+	copy(members, c.chain)
+	c.mu.Unlock()
+
+	status := craqrpc.ChainStatus{Nodes: make([]craqrpc.NodeStatus, 0, len(members))}
+	for _, m := range members {
+		var ns craqrpc.NodeStatus
+		if err := m.client.Call("RPC.Status", struct{}{}, &ns); err != nil {
+			log.Printf("failed to fetch status from node %s: %v\n", m.id, err)
+			continue
+		}
+		status.Nodes = append(status.Nodes, ns)
+	}
+	return status
+}
+
+// PingAll checks every registered node's liveness via RPC.Status. A node is
+// only removed and the chain reconfigured around it once its consecutive
+// failures exceed LivenessFailureThreshold, so a brief network blip
+// doesn't trigger a reshuffle. Intended to be called on a timer.
+func (c *Coordinator) PingAll() {
+	c.mu.Lock()
+	members := make([]*member, len(c.chain))
+	copy(members, c.chain)
+	c.mu.Unlock()
+
+	for _, m := range members {
+		err := m.client.Call("RPC.Status", struct{}{}, &craqrpc.NodeStatus{})
+
+		c.mu.Lock()
+		if err == nil {
+			m.pingFailures = 0
+			c.mu.Unlock()
+			continue
+		}
+		m.pingFailures++
+		dead := m.pingFailures > c.livenessFailureThreshold
+		c.mu.Unlock()
+
+		if dead {
+			log.Printf("node %s failed %d consecutive liveness pings, removing\n", m.id, m.pingFailures)
+			if err := c.removeByID(m.id); err != nil {
+				log.Printf("failed to remove dead node %s: %v\n", m.id, err)
+			}
+		}
+	}
+}
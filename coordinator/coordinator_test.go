@@ -0,0 +1,194 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/despreston/go-craq/craqrpc"
+	"github.com/despreston/go-craq/transport"
+)
+
+// setNeighborCall records one RPC.SetNeighbor invocation seen by a
+// recordingClient, for assertions about what the coordinator pushed.
+type setNeighborCall struct {
+	pos   craqrpc.NeighborPos
+	addrs []string
+}
+
+// recordingClient is a transport.Client double that records every
+// RPC.SetNeighbor call it receives and otherwise always succeeds.
+type recordingClient struct {
+	setNeighborCalls []setNeighborCall
+}
+
+func (c *recordingClient) Call(serviceMethod string, args, reply interface{}) error {
+	if serviceMethod == "RPC.SetNeighbor" {
+		a := args.(*craqrpc.SetNeighborArgs)
+		c.setNeighborCalls = append(c.setNeighborCalls, setNeighborCall{pos: a.Pos, addrs: a.Addrs})
+	}
+	return nil
+}
+
+func (c *recordingClient) Close() error { return nil }
+
+// fakeTransport is a transport.Transporter double that hands back a fresh
+// recordingClient for each ConnectGroup call, keyed by the dialed address,
+// so a test can fetch the client for a given node and inspect what the
+// coordinator pushed to it.
+type fakeTransport struct {
+	clients map[string]*recordingClient
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{clients: make(map[string]*recordingClient)}
+}
+
+func (f *fakeTransport) Connect(path string) (transport.Client, error) {
+	return f.clientFor(path), nil
+}
+
+func (f *fakeTransport) ConnectGroup(addrs []string) (transport.Client, error) {
+	return f.clientFor(addrs[0]), nil
+}
+
+func (f *fakeTransport) clientFor(addr string) *recordingClient {
+	c := &recordingClient{}
+	f.clients[addr] = c
+	return c
+}
+
+func newTestCoordinator() (*Coordinator, *fakeTransport) {
+	ft := newFakeTransport()
+	return New(Opts{Transport: ft}), ft
+}
+
+// threeNodeChain registers A, B, C in order (IDs and addresses "a", "b",
+// "c"), returning the coordinator and the transport.
+func threeNodeChain(t *testing.T) (*Coordinator, *fakeTransport) {
+	t.Helper()
+	c, ft := newTestCoordinator()
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := c.registerNode(id, []string{id}); err != nil {
+			t.Fatalf("registerNode(%q) failed: %v", id, err)
+		}
+	}
+	return c, ft
+}
+
+func TestRegisterNodeBroadcastsNewTailToEveryoneButItself(t *testing.T) {
+	c, ft := threeNodeChain(t)
+
+	if got := len(c.chain); got != 3 {
+		t.Fatalf("chain length = %d, want 3", got)
+	}
+
+	// A should have been told about the tail twice: once when B joined as
+	// tail, and again when C joined as tail.
+	aCalls := ft.clients["a"].setNeighborCalls
+	if len(aCalls) != 2 {
+		t.Fatalf("a got %d SetNeighbor calls, want 2: %+v", len(aCalls), aCalls)
+	}
+	if aCalls[0].pos != craqrpc.NeighborPosTail || aCalls[0].addrs[0] != "b" {
+		t.Errorf("a's first call = %+v, want Tail -> b", aCalls[0])
+	}
+	if aCalls[1].pos != craqrpc.NeighborPosTail || aCalls[1].addrs[0] != "c" {
+		t.Errorf("a's second call = %+v, want Tail -> c", aCalls[1])
+	}
+
+	// B should have been told about the new tail once, when C joined.
+	bCalls := ft.clients["b"].setNeighborCalls
+	if len(bCalls) != 1 {
+		t.Fatalf("b got %d SetNeighbor calls, want 1: %+v", len(bCalls), bCalls)
+	}
+	if bCalls[0].pos != craqrpc.NeighborPosTail || bCalls[0].addrs[0] != "c" {
+		t.Errorf("b's call = %+v, want Tail -> c", bCalls[0])
+	}
+
+	// C is the tail itself; it's never pushed its own address.
+	if got := len(ft.clients["c"].setNeighborCalls); got != 0 {
+		t.Errorf("c got %d SetNeighbor calls, want 0", got)
+	}
+}
+
+func TestReconnectMidChainOnlyUpdatesSuccessorsPrev(t *testing.T) {
+	c, ft := threeNodeChain(t)
+
+	if _, err := c.registerNode("b", []string{"b2"}); err != nil {
+		t.Fatalf("reconnect of b failed: %v", err)
+	}
+
+	// C pulls propagation from B, so only C's Prev pointer should move.
+	cCalls := ft.clients["c"].setNeighborCalls
+	if len(cCalls) != 1 {
+		t.Fatalf("c got %d SetNeighbor calls, want 1: %+v", len(cCalls), cCalls)
+	}
+	if cCalls[0].pos != craqrpc.NeighborPosPrev || cCalls[0].addrs[0] != "b2" {
+		t.Errorf("c's call = %+v, want Prev -> b2", cCalls[0])
+	}
+
+	// A doesn't depend on B's address at all (A's Tail neighbor is the
+	// actual tail, C), so it shouldn't hear about B's reconnect.
+	if got := len(ft.clients["a"].setNeighborCalls); got != 2 {
+		t.Errorf("a got %d SetNeighbor calls after b's reconnect, want 2 (unchanged)", got)
+	}
+
+	idx := c.indexOf(c.byID["b"])
+	if idx != 1 {
+		t.Errorf("b's chain position = %d, want 1 (unchanged)", idx)
+	}
+}
+
+func TestRemoveTailBroadcastsNewTail(t *testing.T) {
+	c, ft := threeNodeChain(t)
+
+	if err := c.removeByID("c"); err != nil {
+		t.Fatalf("removeByID(c) failed: %v", err)
+	}
+
+	if got := len(c.chain); got != 2 {
+		t.Fatalf("chain length = %d, want 2", got)
+	}
+	if c.chain[len(c.chain)-1].id != "b" {
+		t.Fatalf("new tail = %q, want %q", c.chain[len(c.chain)-1].id, "b")
+	}
+
+	// B, the new tail, is told its Tail neighbor is gone.
+	bCalls := ft.clients["b"].setNeighborCalls
+	last := bCalls[len(bCalls)-1]
+	if last.pos != craqrpc.NeighborPosTail || len(last.addrs) != 0 {
+		t.Errorf("b's last call = %+v, want Tail -> empty", last)
+	}
+
+	// A, the only other survivor, is told the tail is now B.
+	aCalls := ft.clients["a"].setNeighborCalls
+	last = aCalls[len(aCalls)-1]
+	if last.pos != craqrpc.NeighborPosTail || last.addrs[0] != "b" {
+		t.Errorf("a's last call = %+v, want Tail -> b", last)
+	}
+}
+
+func TestRemoveHeadClearsSuccessorsPrev(t *testing.T) {
+	c, ft := threeNodeChain(t)
+
+	if err := c.removeByID("a"); err != nil {
+		t.Fatalf("removeByID(a) failed: %v", err)
+	}
+
+	if got := len(c.chain); got != 2 {
+		t.Fatalf("chain length = %d, want 2", got)
+	}
+	if c.chain[0].id != "b" {
+		t.Fatalf("new head = %q, want %q", c.chain[0].id, "b")
+	}
+
+	// B, the new head, is told it has no predecessor.
+	bCalls := ft.clients["b"].setNeighborCalls
+	last := bCalls[len(bCalls)-1]
+	if last.pos != craqrpc.NeighborPosPrev || len(last.addrs) != 0 {
+		t.Errorf("b's last call = %+v, want Prev -> empty", last)
+	}
+
+	// C is unaffected: the tail didn't change, and C doesn't pull from A.
+	if got := len(ft.clients["c"].setNeighborCalls); got != 0 {
+		t.Errorf("c got %d SetNeighbor calls, want 0", got)
+	}
+}
@@ -0,0 +1,93 @@
+package node
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds the Prometheus collectors used to instrument a Node. It's
+// only populated when Opts.MetricsEnabled is set; a nil *metrics disables
+// instrumentation everywhere it's checked.
+type metrics struct {
+	reads              *prometheus.CounterVec
+	writes             *prometheus.CounterVec
+	commits            *prometheus.CounterVec
+	propagationLatency *prometheus.HistogramVec
+	dirtyBacklog       prometheus.Gauge
+	neighborConnected  *prometheus.GaugeVec
+	isHead             prometheus.Gauge
+	isTail             prometheus.Gauge
+	registry           *prometheus.Registry
+}
+
+func newMetrics() *metrics {
+	reg := prometheus.NewRegistry()
+
+	m := &metrics{
+		reads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "craq_node_reads_total",
+			Help: "Reads handled by this node, by key prefix.",
+		}, []string{"key_prefix"}),
+		writes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "craq_node_writes_total",
+			Help: "Writes handled by this node, by key prefix.",
+		}, []string{"key_prefix"}),
+		commits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "craq_node_commits_total",
+			Help: "Commits handled by this node, by key prefix.",
+		}, []string{"key_prefix"}),
+		propagationLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "craq_node_propagation_latency_seconds",
+			Help: "Latency of propagation requests to neighbors, by direction.",
+		}, []string{"direction"}),
+		dirtyBacklog: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "craq_node_dirty_backlog",
+			Help: "Number of uncommitted items currently held by this node.",
+		}),
+		neighborConnected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "craq_node_neighbor_connected",
+			Help: "1 if the neighbor at this chain position is connected, else 0.",
+		}, []string{"position"}),
+		isHead: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "craq_node_is_head",
+			Help: "1 if this node is currently the head of the chain.",
+		}),
+		isTail: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "craq_node_is_tail",
+			Help: "1 if this node is currently the tail of the chain.",
+		}),
+	}
+
+	reg.MustRegister(
+		m.reads, m.writes, m.commits, m.propagationLatency,
+		m.dirtyBacklog, m.neighborConnected, m.isHead, m.isTail,
+	)
+	m.registry = reg
+
+	return m
+}
+
+// Handler serves this node's metrics in Prometheus text format.
+func (m *metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// observeDuration records how long an operation labeled with label took,
+// measured from start.
+func observeDuration(h *prometheus.HistogramVec, label string, start time.Time) {
+	h.WithLabelValues(label).Observe(time.Since(start).Seconds())
+}
+
+// keyPrefix returns the portion of key before its first ':' or '/', or the
+// whole key if it has neither. Used to keep per-key metric cardinality bounded.
+func keyPrefix(key string) string {
+	for i, r := range key {
+		if r == ':' || r == '/' {
+			return key[:i]
+		}
+	}
+	return key
+}
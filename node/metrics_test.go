@@ -0,0 +1,21 @@
+package node
+
+import "testing"
+
+func TestKeyPrefix(t *testing.T) {
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{"user:123", "user"},
+		{"orders/456", "orders"},
+		{"noseparator", "noseparator"},
+		{"", ""},
+	}
+
+	for _, c := range cases {
+		if got := keyPrefix(c.key); got != c.want {
+			t.Errorf("keyPrefix(%q) = %q, want %q", c.key, got, c.want)
+		}
+	}
+}
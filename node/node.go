@@ -9,12 +9,25 @@ import (
 	"net/http"
 	"net/rpc"
 	"sync"
+	"time"
 
 	"github.com/despreston/go-craq/craqrpc"
 	"github.com/despreston/go-craq/transport"
+	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
 )
 
+// shutdownTimeout bounds how long Stop waits for the HTTP server to finish
+// in-flight requests before forcing it closed.
+const shutdownTimeout = 5 * time.Second
+
+// metricsOnce guards registering the /metrics handler on the process-wide
+// http.DefaultServeMux (the same mux net/rpc's HandleHTTP uses), so running a
+// second metrics-enabled Node in the same process doesn't panic on a
+// duplicate pattern registration. Only the first such Node's metrics are
+// served; this is meant for one node per process.
+var metricsOnce sync.Once
+
 var (
 	// ErrNotFound should be returned by storage during a read operation if no
 	// item exists for the given key.
@@ -27,17 +40,28 @@ var (
 
 // neighbor is another node in the chain
 type neighbor struct {
-	client transport.Client
-	path   string
+	client   transport.Client
+	addrs    []string // reachable addresses for this neighbor, in preference order
+	errCount int      // consecutive RPC failures since the last success
+	reported bool     // true once this neighbor has already been reported to the coordinator
+}
+
+// path returns the neighbor's primary address, for logging and reporting.
+func (n neighbor) path() string {
+	if len(n.addrs) == 0 {
+		return ""
+	}
+	return n.addrs[0]
 }
 
 // Item is an object in the Store. A key inside the store might have multiple
 // versions.
 type Item struct {
-	Version   uint64
-	Committed bool
-	Value     []byte
-	Key       string
+	Version     uint64
+	Committed   bool
+	Value       []byte
+	Key         string
+	CommittedAt time.Time // set by the store when Commit is called
 }
 
 type storer interface {
@@ -49,14 +73,36 @@ type storer interface {
 	AllNewerDirty(map[string][]uint64) ([]*Item, error)
 	AllDirty() ([]*Item, error)
 	AllCommitted() ([]*Item, error)
+
+	// NodeID returns this node's persisted identity, or "" if none has been
+	// stored yet.
+	NodeID() (string, error)
+
+	// SetNodeID persists this node's identity so it survives restarts.
+	SetNodeID(string) error
 }
 
 // Opts is for passing options to the Node constructor.
 type Opts struct {
-	Store     storer
-	Path      string
-	CdrPath   string
+	Store   storer
+	Path    string
+	CdrPath string
+
+	// Addrs is the group of addresses this node can be reached at (e.g.
+	// internal + external + alternate NIC). Peers try them in order and fail
+	// over to the next one on a dial error. Defaults to []string{Path} when
+	// empty.
+	Addrs     []string
 	Transport transport.Transporter
+
+	// MaxPeerErrors is the number of consecutive RPC failures against a
+	// neighbor that are tolerated before the node reports it to the
+	// coordinator as unreachable. Zero disables eviction.
+	MaxPeerErrors int
+
+	// MetricsEnabled turns on Prometheus instrumentation, served at /metrics
+	// on the node's existing HTTP listener.
+	MetricsEnabled bool
 }
 
 // Node is what the white paper refers to as a node. This is the client that is
@@ -67,42 +113,172 @@ type Node struct {
 	latest         map[string]uint64                // latest version of a given key
 	CdrPath        string                           // host + port to coordinator
 	cdr            transport.Client
-	Path           string // host + port for rpc communication
+	Path           string   // host + port for rpc communication
+	Addrs          []string // see Opts.Addrs
+	ID             string   // persistent identity, generated on first boot
 	isHead, isTail bool
 	mu             sync.Mutex
 	transport      transport.Transporter
+	MaxPeerErrors  int // see Opts.MaxPeerErrors
+	metrics        *metrics // nil unless Opts.MetricsEnabled
+
+	lastPropagateAt time.Time
+
+	httpServer *http.Server
+
+	startOnce, stopOnce, readyOnce sync.Once
+	started                        bool
+	ready                          chan struct{}
+	stopped                        chan struct{}
+	closed                         chan struct{}
+	err                            error
 }
 
 // New creates a new Node.
 func New(opts Opts) *Node {
+	addrs := opts.Addrs
+	if len(addrs) == 0 && opts.Path != "" {
+		addrs = []string{opts.Path}
+	}
+
+	var m *metrics
+	if opts.MetricsEnabled {
+		m = newMetrics()
+	}
+
 	return &Node{
-		latest:    make(map[string]uint64),
-		neighbors: make(map[craqrpc.NeighborPos]neighbor, 3),
-		CdrPath:   opts.CdrPath,
-		Path:      opts.Path,
-		store:     opts.Store,
-		transport: opts.Transport,
+		latest:        make(map[string]uint64),
+		neighbors:     make(map[craqrpc.NeighborPos]neighbor, 3),
+		CdrPath:       opts.CdrPath,
+		Path:          opts.Path,
+		Addrs:         addrs,
+		store:         opts.Store,
+		transport:     opts.Transport,
+		MaxPeerErrors: opts.MaxPeerErrors,
+		metrics:       m,
+		ready:         make(chan struct{}),
+		stopped:       make(chan struct{}),
+		closed:        make(chan struct{}),
 	}
 }
 
+// Start begins listening for messages and connecting to the coordinator. It
+// is safe to call Start more than once; only the first call has any effect.
+// Start returns immediately; use Ready, Err, and Done to observe progress.
+func (n *Node) Start() {
+	n.startOnce.Do(func() {
+		n.mu.Lock()
+		n.started = true
+		n.mu.Unlock()
+
+		go func() {
+			err := n.ListenAndServe()
+			n.mu.Lock()
+			n.err = err
+			n.mu.Unlock()
+			close(n.closed)
+		}()
+	})
+}
+
+// Ready returns a channel that's closed once the node has connected to the
+// coordinator, learned its position in the chain, and finished propagating
+// with its predecessor, if it has one.
+func (n *Node) Ready() <-chan struct{} { return n.ready }
+
+// Done returns a channel that's closed once ListenAndServe has returned,
+// whether because of Stop or an unrecoverable error.
+func (n *Node) Done() <-chan struct{} { return n.closed }
+
+// Err returns the error ListenAndServe exited with, if any. It's only
+// meaningful after Done has closed.
+func (n *Node) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
+
+// Stop gracefully deregisters the node from the coordinator, closes every
+// neighbor connection, and shuts down the HTTP server, causing
+// ListenAndServe to return nil. Safe to call more than once; only the first
+// call has any effect. Stop blocks until shutdown is complete. If Start was
+// never called, Stop is a no-op: there's nothing running to shut down, and
+// waiting on Done would otherwise block forever.
+func (n *Node) Stop() error {
+	n.mu.Lock()
+	started := n.started
+	n.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	n.stopOnce.Do(func() {
+		close(n.stopped)
+
+		if n.cdr != nil {
+			if err := n.cdr.Call("RPC.RemoveNode", n.ID, &struct{}{}); err != nil {
+				log.Printf("failed to deregister from coordinator: %v\n", err)
+			}
+			n.cdr.Close()
+		}
+
+		n.mu.Lock()
+		for pos, nbr := range n.neighbors {
+			if nbr.client != nil {
+				resetNeighbor(&nbr)
+				n.neighbors[pos] = nbr
+				if n.metrics != nil {
+					n.metrics.neighborConnected.WithLabelValues(pos.String()).Set(0)
+				}
+			}
+		}
+		n.mu.Unlock()
+
+		if n.httpServer != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := n.httpServer.Shutdown(ctx); err != nil {
+				log.Printf("error shutting down http server: %v\n", err)
+			}
+		}
+	})
+
+	<-n.closed
+	return n.Err()
+}
+
 // ListenAndServe starts listening for messages and connects to the coordinator.
 func (n *Node) ListenAndServe() error {
 	nRPC := &RPC{n}
 	rpc.Register(nRPC)
 	rpc.HandleHTTP()
 
+	n.httpServer = &http.Server{Addr: n.Path}
+
+	if n.metrics != nil {
+		metricsOnce.Do(func() {
+			http.Handle("/metrics", n.metrics.Handler())
+		})
+	}
+
 	errg := errgroup.Group{}
-	server := &http.Server{Addr: n.Path}
 
-	errg.Go(server.ListenAndServe)
+	errg.Go(func() error {
+		if err := n.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
 
 	errg.Go(func() error {
 		err := n.ConnectToCoordinator()
 		if err != nil {
 			log.Println(err.Error())
-			server.Shutdown(context.Background())
+			n.httpServer.Shutdown(context.Background())
+			return err
 		}
-		return err
+		n.readyOnce.Do(func() { close(n.ready) })
+		return nil
 	})
 
 	return errg.Wait()
@@ -114,6 +290,11 @@ func (n *Node) ListenAndServe() error {
 // chain and the path to the tail node. The Node announces itself to the
 // neighbor using the path given by the coordinator.
 func (n *Node) ConnectToCoordinator() error {
+	if err := n.ensureNodeID(); err != nil {
+		log.Println("Error loading node identity")
+		return err
+	}
+
 	cdrClient, err := n.transport.Connect(n.CdrPath)
 	if err != nil {
 		log.Println("Error connecting to the coordinator")
@@ -123,15 +304,26 @@ func (n *Node) ConnectToCoordinator() error {
 	log.Printf("Connected to coordinator at %s\n", n.CdrPath)
 	n.cdr = cdrClient
 
-	// Announce self to the Coordinatorr
+	// Announce self to the Coordinator. Registering by ID rather than just
+	// Addrs lets the coordinator recognize this node across restarts on a new
+	// address and keep its chain position instead of re-running propagation.
 	reply := craqrpc.NodeMeta{}
-	if err := cdrClient.Call("RPC.AddNode", n.Path, &reply); err != nil {
+	args := craqrpc.RegisterNodeArgs{ID: n.ID, Addrs: n.Addrs}
+	if err := cdrClient.Call("RPC.RegisterNode", &args, &reply); err != nil {
 		return err
 	}
 
+	n.mu.Lock()
 	n.isHead = reply.IsHead
 	n.isTail = reply.IsTail
-	n.neighbors[craqrpc.NeighborPosTail] = neighbor{path: reply.Tail}
+	n.mu.Unlock()
+
+	if n.metrics != nil {
+		n.metrics.isHead.Set(boolToFloat(n.isHead))
+		n.metrics.isTail.Set(boolToFloat(n.isTail))
+	}
+
+	n.neighbors[craqrpc.NeighborPosTail] = neighbor{addrs: reply.Tail}
 
 	// Connect to tail
 	if !reply.IsTail {
@@ -142,7 +334,7 @@ func (n *Node) ConnectToCoordinator() error {
 	}
 
 	// Connect to predecessor
-	if reply.Prev != "" {
+	if len(reply.Prev) > 0 {
 		if err := n.connectToNode(reply.Prev, craqrpc.NeighborPosPrev); err != nil {
 			log.Printf("Failed to connect to node in ConnectToCoordinator. %v\n", err)
 			return err
@@ -150,7 +342,7 @@ func (n *Node) ConnectToCoordinator() error {
 		if err := n.fullPropagate(); err != nil {
 			return err
 		}
-	} else if n.neighbors[craqrpc.NeighborPosPrev].path != "" {
+	} else if len(n.neighbors[craqrpc.NeighborPosPrev].addrs) > 0 {
 		// Close the connection to the previous predecessor.
 		n.neighbors[craqrpc.NeighborPosPrev].client.Close()
 	}
@@ -158,24 +350,148 @@ func (n *Node) ConnectToCoordinator() error {
 	return nil
 }
 
+// ensureNodeID loads this node's persistent identity from storage, generating
+// and persisting a new UUID on first boot. A stable ID lets the coordinator
+// recognize a restarted node even if it comes back on a new address, rather
+// than treating it as a brand new chain member.
+func (n *Node) ensureNodeID() error {
+	if n.ID != "" {
+		return nil
+	}
+
+	id, err := n.store.NodeID()
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		id = uuid.NewString()
+		if err := n.store.SetNodeID(id); err != nil {
+			return err
+		}
+	}
+
+	n.ID = id
+	return nil
+}
+
 // send FwdPropagate and BackPropagate requests to new predecessor to get fully
 // caught up. Forward propagation should go first so that it has all the dirty
 // items needed before receiving backwards propagation response.
 func (n *Node) fullPropagate() error {
-	prevNeighbor := n.neighbors[craqrpc.NeighborPosPrev].client
-	if err := n.requestFwdPropagation(&prevNeighbor); err != nil {
+	if err := n.requestFwdPropagation(craqrpc.NeighborPosPrev); err != nil {
+		return err
+	}
+	if err := n.requestBackPropagation(craqrpc.NeighborPosPrev); err != nil {
 		return err
 	}
-	return n.requestBackPropagation(&prevNeighbor)
+	n.mu.Lock()
+	n.lastPropagateAt = time.Now()
+	n.mu.Unlock()
+	return nil
 }
 
-func (n *Node) connectToNode(path string, pos craqrpc.NeighborPos) error {
-	client, err := n.transport.Connect(path)
+// callNeighbor invokes serviceMethod on the neighbor at pos, tracking
+// consecutive RPC failures. On an RPC error it transparently re-dials the
+// next address in the neighbor's address group and retries once before
+// giving up, so a single unreachable address doesn't surface as a failure
+// while another address in the group still answers. Once a neighbor's
+// failure count exceeds MaxPeerErrors it's reported to the coordinator,
+// once, as bad so the chain can be reconfigured around it. A successful
+// call resets the counter and the reported flag. The same helper backs
+// write/commit forwarding to the next node in the chain.
+func (n *Node) callNeighbor(pos craqrpc.NeighborPos, serviceMethod string, args, reply interface{}) error {
+	n.mu.Lock()
+	nb := n.neighbors[pos]
+	n.mu.Unlock()
+
+	err := nb.client.Call(serviceMethod, args, reply)
+
+	if err != nil {
+		if redialed, rErr := n.redialNeighbor(pos, nb); rErr == nil {
+			nb = redialed
+			err = nb.client.Call(serviceMethod, args, reply)
+		}
+	}
+
+	n.mu.Lock()
+	nb = n.neighbors[pos]
+
+	if err == nil {
+		nb.errCount = 0
+		nb.reported = false
+		n.neighbors[pos] = nb
+		n.mu.Unlock()
+		return nil
+	}
+
+	nb.errCount++
+	shouldReport := n.MaxPeerErrors > 0 && nb.errCount > n.MaxPeerErrors && !nb.reported
+	if shouldReport {
+		nb.reported = true
+	}
+	n.neighbors[pos] = nb
+	path := nb.path()
+	n.mu.Unlock()
+
+	// Report to the coordinator, if warranted, without holding n.mu: it's a
+	// blocking network call, and every other method that touches n.neighbors
+	// takes the same lock.
+	if shouldReport {
+		log.Printf("neighbor %s exceeded MaxPeerErrors (%d), reporting to coordinator\n", path, n.MaxPeerErrors)
+		if rErr := n.reportBadNode(path); rErr != nil {
+			log.Printf("failed to report bad node %s to coordinator: %v\n", path, rErr)
+		}
+		if n.metrics != nil {
+			n.metrics.neighborConnected.WithLabelValues(pos.String()).Set(0)
+		}
+	}
+
+	return err
+}
+
+// redialNeighbor re-dials the neighbor's address group, using whichever
+// address answers first, and swaps in the resulting client. Called after an
+// RPC error so the next call has a chance to go out over a working address
+// instead of the one that just failed.
+func (n *Node) redialNeighbor(pos craqrpc.NeighborPos, nb neighbor) (neighbor, error) {
+	if len(nb.addrs) == 0 {
+		return neighbor{}, errors.New("neighbor has no addresses to redial")
+	}
+
+	client, err := n.transport.ConnectGroup(nb.addrs)
+	if err != nil {
+		return neighbor{}, err
+	}
+
+	if nb.client != nil {
+		nb.client.Close()
+	}
+	nb.client = client
+
+	n.mu.Lock()
+	n.neighbors[pos] = nb
+	n.mu.Unlock()
+
+	return nb, nil
+}
+
+// reportBadNode tells the coordinator that the neighbor at path has exceeded
+// its error budget so it can be dropped from the chain and the chain
+// reconfigured around it.
+func (n *Node) reportBadNode(path string) error {
+	return n.cdr.Call("RPC.ReportBadNode", &craqrpc.BadNodeArgs{Path: path}, &struct{}{})
+}
+
+// connectToNode dials the given address group, using whichever address
+// answers first, and stores the resulting client as the neighbor at pos.
+func (n *Node) connectToNode(addrs []string, pos craqrpc.NeighborPos) error {
+	client, err := n.transport.ConnectGroup(addrs)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("connected to %s\n", path)
+	log.Printf("connected to %v\n", addrs)
 
 	// Disconnect from current neighbor if there's one connected.
 	nbr := n.neighbors[pos]
@@ -185,12 +501,24 @@ func (n *Node) connectToNode(path string, pos craqrpc.NeighborPos) error {
 
 	n.neighbors[pos] = neighbor{
 		client: client,
-		path:   path,
+		addrs:  addrs,
+	}
+
+	if n.metrics != nil {
+		n.metrics.neighborConnected.WithLabelValues(pos.String()).Set(1)
 	}
 
 	return nil
 }
 
+// boolToFloat converts b to a Prometheus-friendly 1 or 0.
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func (n *Node) writePropagated(reply *craqrpc.PropagateResponse) error {
 	// Save items from reply to store.
 	for key, forKey := range *reply {
@@ -199,6 +527,9 @@ func (n *Node) writePropagated(reply *craqrpc.PropagateResponse) error {
 				log.Printf("Failed to write item %+v to store: %#v\n", item, err)
 				return err
 			}
+			if n.metrics != nil {
+				n.metrics.writes.WithLabelValues(keyPrefix(key)).Inc()
+			}
 		}
 	}
 	return nil
@@ -212,6 +543,9 @@ func (n *Node) commitPropagated(reply *craqrpc.PropagateResponse) error {
 				log.Printf("Failed to commit item %+v to store: %#v\n", item, err)
 				return err
 			}
+			if n.metrics != nil {
+				n.metrics.commits.WithLabelValues(keyPrefix(key)).Inc()
+			}
 		}
 	}
 	return nil
@@ -225,19 +559,29 @@ func propagateRequestFromItems(items []*Item) craqrpc.PropagateRequest {
 	return req
 }
 
-// requestFwdPropagation asks client to respond with all uncommitted (dirty)
-// items that this node either does not have or are newer than what this node
-// has.
-func (n *Node) requestFwdPropagation(client *transport.Client) error {
+// requestFwdPropagation asks the neighbor at pos to respond with all
+// uncommitted (dirty) items that this node either does not have or are newer
+// than what this node has.
+func (n *Node) requestFwdPropagation(pos craqrpc.NeighborPos) error {
 	dirty, err := n.store.AllDirty()
 	if err != nil {
 		log.Printf("Failed to get all dirty items: %#v\n", err)
 		return err
 	}
 
+	if n.metrics != nil {
+		n.metrics.dirtyBacklog.Set(float64(len(dirty)))
+	}
+
 	reply := craqrpc.PropagateResponse{}
 	args := propagateRequestFromItems(dirty)
-	if err := (*client).Call("RPC.FwdPropagate", &args, &reply); err != nil {
+
+	start := time.Now()
+	err = n.callNeighbor(pos, "RPC.FwdPropagate", &args, &reply)
+	if n.metrics != nil {
+		observeDuration(n.metrics.propagationLatency, "fwd", start)
+	}
+	if err != nil {
 		log.Printf("Failed during forward propagation: %#v\n", err)
 		return err
 	}
@@ -245,9 +589,10 @@ func (n *Node) requestFwdPropagation(client *transport.Client) error {
 	return n.writePropagated(&reply)
 }
 
-// requestBackPropagation asks client to respond with all committed items that
-// this node either does not have or are newer than what this node has.
-func (n *Node) requestBackPropagation(client *transport.Client) error {
+// requestBackPropagation asks the neighbor at pos to respond with all
+// committed items that this node either does not have or are newer than what
+// this node has.
+func (n *Node) requestBackPropagation(pos craqrpc.NeighborPos) error {
 	committed, err := n.store.AllCommitted()
 	if err != nil {
 		log.Printf("Failed to get all committed items: %#v\n", err)
@@ -256,7 +601,13 @@ func (n *Node) requestBackPropagation(client *transport.Client) error {
 
 	args := propagateRequestFromItems(committed)
 	reply := craqrpc.PropagateResponse{}
-	if err := (*client).Call("RPC.BackPropagate", &args, &reply); err != nil {
+
+	start := time.Now()
+	err = n.callNeighbor(pos, "RPC.BackPropagate", &args, &reply)
+	if n.metrics != nil {
+		observeDuration(n.metrics.propagationLatency, "back", start)
+	}
+	if err != nil {
 		log.Printf("Failed during back propagation: %#v\n", err)
 		return err
 	}
@@ -264,6 +615,145 @@ func (n *Node) requestBackPropagation(client *transport.Client) error {
 	return n.commitPropagated(&reply)
 }
 
+// resolveRead returns the item to hand back to a client for the given read
+// mode. item is this node's local copy, which may be dirty. ReadModeEventual
+// returns it as-is. ReadModeStrong and ReadModeBoundedStaleness may instead
+// consult the tail for the latest committed version, trading latency for a
+// stronger consistency guarantee on a per-request basis.
+func (n *Node) resolveRead(item *Item, mode craqrpc.ReadMode, maxAge time.Duration) (*Item, error) {
+	if n.metrics != nil {
+		n.metrics.reads.WithLabelValues(keyPrefix(item.Key)).Inc()
+	}
+
+	switch mode {
+	case craqrpc.ReadModeEventual:
+		return item, nil
+
+	case craqrpc.ReadModeBoundedStaleness:
+		if item.Committed && time.Since(item.CommittedAt) <= maxAge {
+			return item, nil
+		}
+		fallthrough
+
+	case craqrpc.ReadModeStrong:
+		version, err := n.tailVersionQuery(item.Key)
+		if err != nil {
+			return nil, err
+		}
+		if item.Committed && item.Version == version {
+			return item, nil
+		}
+		return n.store.ReadVersion(item.Key, version)
+
+	default:
+		return item, nil
+	}
+}
+
+// tailVersionQuery asks the tail for the latest committed version number it
+// has for key, so a strong or bounded-staleness read can tell whether this
+// node's local copy is current.
+func (n *Node) tailVersionQuery(key string) (uint64, error) {
+	if n.isTail {
+		item, err := n.store.Read(key)
+		if err != nil {
+			return 0, err
+		}
+		return item.Version, nil
+	}
+
+	var version uint64
+	err := n.callNeighbor(craqrpc.NeighborPosTail, "RPC.VersionQuery", &key, &version)
+	return version, err
+}
+
+// Status returns a snapshot of this node's chain position and propagation
+// lag. It backs the RPC.Status handler, which the coordinator's
+// RPC.ChainStatus fans out to across every registered node.
+func (n *Node) Status() (*craqrpc.NodeStatus, error) {
+	dirty, err := n.store.AllDirty()
+	if err != nil {
+		return nil, err
+	}
+
+	committed, err := n.store.AllCommitted()
+	if err != nil {
+		return nil, err
+	}
+
+	// The coordinator's RPC.ChainStatus and PingAll both call RPC.Status,
+	// which keeps this gauge fresh even during steady state when no
+	// propagation is happening to update it otherwise.
+	if n.metrics != nil {
+		n.metrics.dirtyBacklog.Set(float64(len(dirty)))
+	}
+
+	n.mu.Lock()
+	neighbors := make(map[craqrpc.NeighborPos]string, len(n.neighbors))
+	for pos, nbr := range n.neighbors {
+		neighbors[pos] = nbr.path()
+	}
+	isHead, isTail := n.isHead, n.isTail
+	lastPropagateAt := n.lastPropagateAt
+	n.mu.Unlock()
+
+	return &craqrpc.NodeStatus{
+		ID:              n.ID,
+		Path:            n.Path,
+		IsHead:          isHead,
+		IsTail:          isTail,
+		Neighbors:       neighbors,
+		DirtyCount:      len(dirty),
+		CommittedCount:  len(committed),
+		LastPropagateAt: lastPropagateAt,
+	}, nil
+}
+
+// SetNeighbor lets the coordinator push an updated address group for the
+// neighbor at pos without waiting for this node's own reconnect cycle, e.g.
+// after the coordinator reconfigures the chain around a node that was
+// removed or that reconnected under a new address. An empty addrs means
+// this node no longer has a neighbor at pos: losing the Prev neighbor makes
+// this node the head, and losing the Tail neighbor makes it the tail, so
+// SetNeighbor updates isHead/isTail to match in either direction.
+func (n *Node) SetNeighbor(pos craqrpc.NeighborPos, addrs []string) error {
+	isEdge := len(addrs) == 0
+
+	n.mu.Lock()
+	switch pos {
+	case craqrpc.NeighborPosPrev:
+		n.isHead = isEdge
+	case craqrpc.NeighborPosTail:
+		n.isTail = isEdge
+	}
+	n.mu.Unlock()
+
+	if n.metrics != nil {
+		n.metrics.isHead.Set(boolToFloat(n.isHead))
+		n.metrics.isTail.Set(boolToFloat(n.isTail))
+	}
+
+	if isEdge {
+		n.mu.Lock()
+		nbr := n.neighbors[pos]
+		resetNeighbor(&nbr)
+		n.neighbors[pos] = nbr
+		n.mu.Unlock()
+		if n.metrics != nil {
+			n.metrics.neighborConnected.WithLabelValues(pos.String()).Set(0)
+		}
+		return nil
+	}
+
+	if err := n.connectToNode(addrs, pos); err != nil {
+		return err
+	}
+	if pos == craqrpc.NeighborPosPrev {
+		return n.fullPropagate()
+	}
+	return nil
+}
+
 // resetNeighbor closes any open connection and resets the object.
 func resetNeighbor(n *neighbor) {
 	n.client.Close()
@@ -0,0 +1,259 @@
+package node
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/despreston/go-craq/craqrpc"
+	"github.com/despreston/go-craq/transport"
+)
+
+// fakeClient is a transport.Client double whose Call can be scripted to fail
+// a fixed number of times before succeeding, or to fail forever.
+type fakeClient struct {
+	calls     []string
+	failTimes int // number of Calls that should return err before succeeding
+	err       error
+}
+
+func (f *fakeClient) Call(serviceMethod string, args, reply interface{}) error {
+	f.calls = append(f.calls, serviceMethod)
+	if f.failTimes > 0 {
+		f.failTimes--
+		return f.err
+	}
+	return nil
+}
+
+func (f *fakeClient) Close() error { return nil }
+
+// fakeTransporter is a transport.Transporter double that always hands back a
+// fixed client from ConnectGroup, so tests can verify callNeighbor redials
+// through the group rather than exercising real dialing.
+type fakeTransporter struct {
+	client            transport.Client
+	connectGroupCalls int
+}
+
+func (f *fakeTransporter) Connect(path string) (transport.Client, error) {
+	return f.client, nil
+}
+
+func (f *fakeTransporter) ConnectGroup(addrs []string) (transport.Client, error) {
+	f.connectGroupCalls++
+	return f.client, nil
+}
+
+func newTestNode() *Node {
+	return &Node{
+		neighbors: make(map[craqrpc.NeighborPos]neighbor, 3),
+	}
+}
+
+func TestCallNeighborResetsErrCountOnSuccess(t *testing.T) {
+	n := newTestNode()
+	n.MaxPeerErrors = 2
+	client := &fakeClient{}
+	n.neighbors[craqrpc.NeighborPosPrev] = neighbor{client: client, errCount: 1}
+
+	if err := n.callNeighbor(craqrpc.NeighborPosPrev, "RPC.FwdPropagate", nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	nb := n.neighbors[craqrpc.NeighborPosPrev]
+	if nb.errCount != 0 {
+		t.Errorf("errCount = %d, want 0", nb.errCount)
+	}
+}
+
+func TestCallNeighborReportsOnceAfterThreshold(t *testing.T) {
+	n := newTestNode()
+	n.MaxPeerErrors = 2
+	failErr := errors.New("dial tcp: connection refused")
+	client := &fakeClient{failTimes: 10, err: failErr}
+	n.neighbors[craqrpc.NeighborPosPrev] = neighbor{
+		client: client,
+		addrs:  []string{"localhost:9000"},
+	}
+	// Redialing hands back the same failing client, so each callNeighbor
+	// consumes two of its failures and all five calls still fail.
+	n.transport = &fakeTransporter{client: client}
+	// The fake coordinator client records every RPC.ReportBadNode, so the
+	// test can check the reported flag limits it to a single report.
+	reportCalls := 0
+	n.cdr = &fakeClient{}
+
+	for i := 0; i < 5; i++ {
+		err := n.callNeighbor(craqrpc.NeighborPosPrev, "RPC.FwdPropagate", nil, nil)
+		if !errors.Is(err, failErr) {
+			t.Fatalf("call %d: err = %v, want %v", i, err, failErr)
+		}
+	}
+
+	cdrClient := n.cdr.(*fakeClient)
+	for _, c := range cdrClient.calls {
+		if c == "RPC.ReportBadNode" {
+			reportCalls++
+		}
+	}
+
+	if reportCalls != 1 {
+		t.Errorf("RPC.ReportBadNode called %d times, want exactly 1", reportCalls)
+	}
+
+	nb := n.neighbors[craqrpc.NeighborPosPrev]
+	if nb.errCount != 5 {
+		t.Errorf("errCount = %d, want 5 (still tracked after reporting)", nb.errCount)
+	}
+}
+
+func TestCallNeighborRedialsOnError(t *testing.T) {
+	n := newTestNode()
+	failErr := errors.New("connection reset")
+	deadClient := &fakeClient{failTimes: 1, err: failErr}
+	liveClient := &fakeClient{}
+	transport := &fakeTransporter{client: liveClient}
+
+	n.transport = transport
+	n.neighbors[craqrpc.NeighborPosTail] = neighbor{
+		client: deadClient,
+		addrs:  []string{"10.0.0.1:9000", "10.0.0.2:9000"},
+	}
+
+	if err := n.callNeighbor(craqrpc.NeighborPosTail, "RPC.VersionQuery", nil, nil); err != nil {
+		t.Fatalf("unexpected error after redial: %v", err)
+	}
+
+	if transport.connectGroupCalls != 1 {
+		t.Errorf("ConnectGroup called %d times, want 1", transport.connectGroupCalls)
+	}
+
+	nb := n.neighbors[craqrpc.NeighborPosTail]
+	if nb.client != liveClient {
+		t.Error("neighbor client was not swapped to the redialed client")
+	}
+	if nb.errCount != 0 {
+		t.Errorf("errCount = %d, want 0 after a successful redial", nb.errCount)
+	}
+}
+
+// fakeStore is a storer double that keeps only the node ID in memory, for
+// tests that only care about the NodeID bookkeeping.
+type fakeStore struct {
+	storer
+	id string
+}
+
+func (f *fakeStore) NodeID() (string, error)   { return f.id, nil }
+func (f *fakeStore) SetNodeID(id string) error { f.id = id; return nil }
+
+func TestEnsureNodeIDGeneratesAndPersistsOnFirstBoot(t *testing.T) {
+	n := newTestNode()
+	store := &fakeStore{}
+	n.store = store
+
+	if err := n.ensureNodeID(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.ID == "" {
+		t.Fatal("ensureNodeID left n.ID empty")
+	}
+	if store.id != n.ID {
+		t.Errorf("store.id = %q, want it persisted as %q", store.id, n.ID)
+	}
+}
+
+func TestEnsureNodeIDReusesPersistedID(t *testing.T) {
+	n := newTestNode()
+	n.store = &fakeStore{id: "existing-id"}
+
+	if err := n.ensureNodeID(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.ID != "existing-id" {
+		t.Errorf("n.ID = %q, want %q", n.ID, "existing-id")
+	}
+}
+
+func TestStopWithoutStartIsNoop(t *testing.T) {
+	n := New(Opts{})
+
+	done := make(chan error, 1)
+	go func() { done <- n.Stop() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Stop() = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop() blocked forever when Start was never called")
+	}
+}
+
+// versionClient is a transport.Client double for RPC.VersionQuery: it always
+// reports a fixed version for the tail.
+type versionClient struct{ version uint64 }
+
+func (v *versionClient) Call(serviceMethod string, args, reply interface{}) error {
+	if out, ok := reply.(*uint64); ok {
+		*out = v.version
+	}
+	return nil
+}
+
+func (v *versionClient) Close() error { return nil }
+
+// fakeReadStore is a storer double that serves a single fixed item from
+// ReadVersion, for resolveRead tests that need to fetch a newer version.
+type fakeReadStore struct {
+	storer
+	item *Item
+}
+
+func (f *fakeReadStore) ReadVersion(key string, version uint64) (*Item, error) {
+	return f.item, nil
+}
+
+func TestResolveReadEventualReturnsLocalItemAsIs(t *testing.T) {
+	n := newTestNode()
+	item := &Item{Key: "k", Version: 1}
+
+	got, err := n.resolveRead(item, craqrpc.ReadModeEventual, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != item {
+		t.Error("resolveRead did not return the local item unchanged")
+	}
+}
+
+func TestResolveReadBoundedStalenessWithinBoundSkipsTailQuery(t *testing.T) {
+	n := newTestNode()
+	item := &Item{Key: "k", Version: 1, Committed: true, CommittedAt: time.Now()}
+
+	got, err := n.resolveRead(item, craqrpc.ReadModeBoundedStaleness, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != item {
+		t.Error("resolveRead should return the local item when within the staleness bound")
+	}
+}
+
+func TestResolveReadStrongFetchesNewerVersionWhenStale(t *testing.T) {
+	n := newTestNode()
+	newer := &Item{Key: "k", Version: 2, Committed: true}
+	n.store = &fakeReadStore{item: newer}
+	n.neighbors[craqrpc.NeighborPosTail] = neighbor{client: &versionClient{version: 2}}
+
+	local := &Item{Key: "k", Version: 1, Committed: true}
+	got, err := n.resolveRead(local, craqrpc.ReadModeStrong, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != newer {
+		t.Error("resolveRead did not fetch the newer committed version from the tail")
+	}
+}